@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// alertPhase is a state in the per-resource breach state machine:
+// OK -> Pending -> Firing -> OK (via a Resolved transition).
+type alertPhase string
+
+const (
+	phaseOK      alertPhase = "ok"
+	phasePending alertPhase = "pending"
+	phaseFiring  alertPhase = "firing"
+)
+
+type resourceState struct {
+	phase       alertPhase
+	breachSince time.Time // when the threshold was first breached in the current pending/firing run
+	firedAt     time.Time // when the state last transitioned into firing
+	lastSent    time.Time // when a firing notification was last (re)sent
+	severity    string    // severity of the latest firing sample, whether or not it was sent; echoed back on resolve
+}
+
+// stateTransition is a firing or resolved event the engine wants
+// delivered to the alert sinks for a resource.
+type stateTransition struct {
+	Resource    string
+	Status      string // "firing" or "resolved"
+	Severity    string // "warning" or "critical"; the fallback (-alert-status) for single-threshold rules
+	FirstSeen   time.Time
+	FiredAt     time.Time
+	Fingerprint string
+}
+
+// severityFor maps a rule and its current value to a severity label. A
+// rule with equal warning/critical thresholds (the shape produced from
+// flat CLI flags) keeps using fallback so single-threshold setups behave
+// exactly as before structured severities existed.
+func severityFor(rule ResourceRule, actual float64, fallback string) string {
+	if rule.Warning == rule.Critical {
+		return fallback
+	}
+	if actual > rule.Critical {
+		return "critical"
+	}
+	return "warning"
+}
+
+// alertEngine turns raw per-sample threshold comparisons into a
+// deduplicated stream of firing/resolved transitions. A threshold must be
+// breached for forDuration before it fires, repeated firing notifications
+// while a resource stays breached are suppressed to repeatInterval, and a
+// resolved transition is only emitted once the metric falls back below
+// threshold-hysteresis. This is what stops a box stuck at 81% from
+// flooding the webhook every sample interval.
+type alertEngine struct {
+	mu             sync.Mutex
+	states         map[string]*resourceState
+	forDuration    time.Duration
+	repeatInterval time.Duration
+	hysteresis     float64
+	hostname       string
+}
+
+func newAlertEngine(forDuration, repeatInterval time.Duration, hysteresis float64, hostname string) *alertEngine {
+	return &alertEngine{
+		states:         make(map[string]*resourceState),
+		forDuration:    forDuration,
+		repeatInterval: repeatInterval,
+		hysteresis:     hysteresis,
+		hostname:       hostname,
+	}
+}
+
+// evaluate feeds one sample's actual value for rule through the state
+// machine and returns the transition to emit, or nil if nothing should be
+// sent for this sample. fallbackSeverity is used for the legacy,
+// single-threshold flag path; see severityFor.
+func (e *alertEngine) evaluate(rule ResourceRule, actual float64, fallbackSeverity string, now time.Time) *stateTransition {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	resource := rule.Name()
+	st, ok := e.states[resource]
+	if !ok {
+		st = &resourceState{phase: phaseOK}
+		e.states[resource] = st
+	}
+
+	breached := actual > rule.Warning
+	recovered := actual <= rule.Warning-e.hysteresis
+
+	switch st.phase {
+	case phaseOK:
+		if !breached {
+			return nil
+		}
+		st.phase = phasePending
+		st.breachSince = now
+		if e.forDuration > 0 {
+			return nil
+		}
+		// forDuration <= 0 means "fire immediately", so don't wait for a
+		// second sample to observe the breach that was already just seen.
+		return e.fire(rule, st, actual, fallbackSeverity, now)
+
+	case phasePending:
+		if !breached {
+			st.phase = phaseOK
+			return nil
+		}
+		if now.Sub(st.breachSince) < e.forDuration {
+			return nil
+		}
+		return e.fire(rule, st, actual, fallbackSeverity, now)
+
+	case phaseFiring:
+		if recovered {
+			transition := &stateTransition{
+				Resource:    resource,
+				Status:      "resolved",
+				Severity:    st.severity,
+				FirstSeen:   st.breachSince,
+				FiredAt:     st.firedAt,
+				Fingerprint: e.fingerprint(resource),
+			}
+			*st = resourceState{phase: phaseOK}
+			return transition
+		}
+		st.severity = severityFor(rule, actual, fallbackSeverity)
+		if now.Sub(st.lastSent) >= e.repeatInterval {
+			st.lastSent = now
+			return &stateTransition{
+				Resource:    resource,
+				Status:      "firing",
+				Severity:    st.severity,
+				FirstSeen:   st.breachSince,
+				FiredAt:     st.firedAt,
+				Fingerprint: e.fingerprint(resource),
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// fire transitions st into Firing and returns the resulting transition.
+// Callers must hold e.mu and have already set st.breachSince for this run.
+func (e *alertEngine) fire(rule ResourceRule, st *resourceState, actual float64, fallbackSeverity string, now time.Time) *stateTransition {
+	resource := rule.Name()
+	st.phase = phaseFiring
+	st.firedAt = now
+	st.lastSent = now
+	st.severity = severityFor(rule, actual, fallbackSeverity)
+	return &stateTransition{
+		Resource:    resource,
+		Status:      "firing",
+		Severity:    st.severity,
+		FirstSeen:   st.breachSince,
+		FiredAt:     st.firedAt,
+		Fingerprint: e.fingerprint(resource),
+	}
+}
+
+// fingerprint returns a stable id correlating start/resolve pairs for a
+// resource on this host, independent of the alert's timestamp.
+func (e *alertEngine) fingerprint(resource string) string {
+	sum := sha256.Sum256([]byte(e.hostname + "|" + resource))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertEngineEvaluate(t *testing.T) {
+	rule := ResourceRule{Kind: resourceCPU, Warning: 80, Critical: 80}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("fires immediately when forDuration is zero", func(t *testing.T) {
+		e := newAlertEngine(0, 5*time.Minute, 5, "host")
+		tr := e.evaluate(rule, 90, "warning", base)
+		if tr == nil || tr.Status != "firing" {
+			t.Fatalf("expected an immediate firing transition, got %+v", tr)
+		}
+	})
+
+	t.Run("waits for forDuration before firing", func(t *testing.T) {
+		e := newAlertEngine(30*time.Second, 5*time.Minute, 5, "host")
+
+		if tr := e.evaluate(rule, 90, "warning", base); tr != nil {
+			t.Fatalf("expected no transition while pending, got %+v", tr)
+		}
+		if tr := e.evaluate(rule, 90, "warning", base.Add(10*time.Second)); tr != nil {
+			t.Fatalf("expected no transition before forDuration elapses, got %+v", tr)
+		}
+		tr := e.evaluate(rule, 90, "warning", base.Add(30*time.Second))
+		if tr == nil || tr.Status != "firing" {
+			t.Fatalf("expected firing once forDuration elapses, got %+v", tr)
+		}
+		if !tr.FirstSeen.Equal(base) {
+			t.Fatalf("expected FirstSeen to be the first breached sample, got %v", tr.FirstSeen)
+		}
+	})
+
+	t.Run("pending resets to OK on recovery before firing", func(t *testing.T) {
+		e := newAlertEngine(30*time.Second, 5*time.Minute, 5, "host")
+		e.evaluate(rule, 90, "warning", base)
+		if tr := e.evaluate(rule, 50, "warning", base.Add(5*time.Second)); tr != nil {
+			t.Fatalf("expected no transition recovering from pending, got %+v", tr)
+		}
+		// A fresh breach afterward should start a new pending window, not
+		// reuse the old breachSince.
+		tr := e.evaluate(rule, 90, "warning", base.Add(10*time.Second))
+		if tr != nil {
+			t.Fatalf("expected no immediate transition re-entering pending, got %+v", tr)
+		}
+	})
+
+	t.Run("suppresses repeat firing within repeatInterval", func(t *testing.T) {
+		e := newAlertEngine(0, 5*time.Minute, 5, "host")
+		e.evaluate(rule, 90, "warning", base)
+		if tr := e.evaluate(rule, 90, "warning", base.Add(time.Minute)); tr != nil {
+			t.Fatalf("expected repeat firing to be suppressed, got %+v", tr)
+		}
+		tr := e.evaluate(rule, 90, "warning", base.Add(6*time.Minute))
+		if tr == nil || tr.Status != "firing" {
+			t.Fatalf("expected a repeat firing once repeatInterval elapses, got %+v", tr)
+		}
+	})
+
+	t.Run("resolves once hysteresis band is cleared", func(t *testing.T) {
+		e := newAlertEngine(0, 5*time.Minute, 5, "host")
+		e.evaluate(rule, 90, "warning", base)
+		if tr := e.evaluate(rule, 78, "warning", base.Add(time.Second)); tr != nil {
+			t.Fatalf("expected no resolve within the hysteresis band, got %+v", tr)
+		}
+		tr := e.evaluate(rule, 74, "warning", base.Add(2*time.Second))
+		if tr == nil || tr.Status != "resolved" {
+			t.Fatalf("expected resolved once below warning-hysteresis, got %+v", tr)
+		}
+	})
+
+	t.Run("severity follows rule thresholds once distinct", func(t *testing.T) {
+		e := newAlertEngine(0, 5*time.Minute, 5, "host")
+		severityRule := ResourceRule{Kind: resourceCPU, Warning: 70, Critical: 90}
+		tr := e.evaluate(severityRule, 95, "warning", base)
+		if tr == nil || tr.Severity != "critical" {
+			t.Fatalf("expected critical severity above the critical threshold, got %+v", tr)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Package collector gathers system resource metrics from the host. It
+// exists so that the sampling logic can be shared between the push-based
+// webhook alerting loop and the pull-based Prometheus scrape handler.
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// DiskUsage is the usage percentage for a single monitored mount.
+type DiskUsage struct {
+	Path    string
+	Percent float64
+}
+
+// Snapshot contains the collected metrics for a single sample.
+type Snapshot struct {
+	CPUPercent    float64
+	MemPercent    float64
+	Disks         []DiskUsage
+	IOWaitPercent float64
+}
+
+// Collector samples the host's current resource usage.
+type Collector interface {
+	// Prime readies any stateful counters (e.g. CPU percent deltas) before
+	// the first real sample is taken.
+	Prime(ctx context.Context) error
+	Collect(ctx context.Context) (Snapshot, error)
+}
+
+// GopsutilCollector implements Collector on top of gopsutil.
+type GopsutilCollector struct {
+	DiskPaths    []string
+	prevCPUTimes *cpu.TimesStat
+}
+
+// New returns a GopsutilCollector that reports disk usage for each of
+// diskPaths.
+func New(diskPaths []string) *GopsutilCollector {
+	return &GopsutilCollector{DiskPaths: diskPaths}
+}
+
+// Prime primes the CPU percent calculation; the first call with interval=0
+// otherwise returns 0.
+func (c *GopsutilCollector) Prime(ctx context.Context) error {
+	if _, err := cpu.PercentWithContext(ctx, 0, false); err != nil {
+		return fmt.Errorf("priming CPU metrics: %w", err)
+	}
+	return nil
+}
+
+// Collect gathers one sample of CPU, memory, disk and IO-wait usage.
+func (c *GopsutilCollector) Collect(ctx context.Context) (Snapshot, error) {
+	cpuPercent, err := c.currentCPUPercent(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	memStats, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("fetching memory: %w", err)
+	}
+
+	disks := make([]DiskUsage, 0, len(c.DiskPaths))
+	for _, path := range c.DiskPaths {
+		diskStats, err := disk.UsageWithContext(ctx, path)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("fetching disk usage for %s: %w", path, err)
+		}
+		disks = append(disks, DiskUsage{Path: path, Percent: Round(diskStats.UsedPercent, 1)})
+	}
+
+	// IO-wait is best-effort: a transient /proc read failure shouldn't
+	// fail the whole sample, it just reports as 0 for this tick.
+	ioWaitPercent, _ := c.currentIOWaitPercent(ctx)
+
+	return Snapshot{
+		CPUPercent:    Round(cpuPercent, 1),
+		MemPercent:    Round(memStats.UsedPercent, 1),
+		Disks:         disks,
+		IOWaitPercent: Round(ioWaitPercent, 1),
+	}, nil
+}
+
+func (c *GopsutilCollector) currentCPUPercent(ctx context.Context) (float64, error) {
+	values, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return 0, fmt.Errorf("fetching CPU percent: %w", err)
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no CPU percentage data returned")
+	}
+	return values[0], nil
+}
+
+func (c *GopsutilCollector) currentIOWaitPercent(ctx context.Context) (float64, error) {
+	stats, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		return 0, fmt.Errorf("fetching CPU times: %w", err)
+	}
+	if len(stats) == 0 {
+		return 0, fmt.Errorf("no CPU times returned")
+	}
+
+	current := stats[0]
+	if c.prevCPUTimes == nil {
+		c.prevCPUTimes = &cpu.TimesStat{}
+		*c.prevCPUTimes = current
+		return 0, nil
+	}
+
+	prev := *c.prevCPUTimes
+	totalDelta := current.Total() - prev.Total()
+	if totalDelta <= 0 {
+		*c.prevCPUTimes = current
+		return 0, nil
+	}
+
+	ioWaitDelta := current.Iowait - prev.Iowait
+	*c.prevCPUTimes = current
+	if ioWaitDelta <= 0 {
+		return 0, nil
+	}
+
+	percent := (ioWaitDelta / totalDelta) * 100
+	if percent < 0 {
+		return 0, nil
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return percent, nil
+}
+
+// Round rounds value to the given number of decimal places.
+func Round(value float64, precision int) float64 {
+	factor := math.Pow(10, float64(precision))
+	return math.Round(value*factor) / factor
+}
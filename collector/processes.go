@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// processEnumerationTimeout bounds how long CollectTopProcesses waits on
+// /proc enumeration, so a hung read can't stall the sample loop that calls
+// it from triggerAlert.
+const processEnumerationTimeout = 2 * time.Second
+
+// ProcessInfo describes one offending process captured when a threshold is
+// breached, so the alert body can explain why the box is hot.
+type ProcessInfo struct {
+	PID         int32
+	Name        string
+	Cmdline     string
+	CPUPercent  float64
+	RSSBytes    uint64
+	IOWaitState bool // true if the process was in uninterruptible sleep ("D"), i.e. likely blocked on IO
+}
+
+// CollectTopProcesses returns the top n processes on the host, ordered by
+// sortBy ("cpu", "rss" or "iowait"). Process enumeration runs under a
+// bounded timeout independent of ctx's own deadline.
+func CollectTopProcesses(ctx context.Context, n int, sortBy string) ([]ProcessInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, processEnumerationTimeout)
+	defer cancel()
+
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing processes: %w", err)
+	}
+
+	infos := make([]ProcessInfo, 0, len(procs))
+	for _, p := range procs {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		cmdline, _ := p.CmdlineWithContext(ctx)
+		cpuPercent, _ := p.CPUPercentWithContext(ctx)
+		memInfo, _ := p.MemoryInfoWithContext(ctx)
+		status, _ := p.StatusWithContext(ctx)
+
+		var rss uint64
+		if memInfo != nil {
+			rss = memInfo.RSS
+		}
+
+		infos = append(infos, ProcessInfo{
+			PID:         p.Pid,
+			Name:        name,
+			Cmdline:     cmdline,
+			CPUPercent:  Round(cpuPercent, 1),
+			RSSBytes:    rss,
+			IOWaitState: containsState(status, process.Blocked), // gopsutil reports state "D" as Blocked
+		})
+	}
+
+	sortProcesses(infos, sortBy)
+	if n >= 0 && len(infos) > n {
+		infos = infos[:n]
+	}
+	return infos, nil
+}
+
+func sortProcesses(infos []ProcessInfo, sortBy string) {
+	switch sortBy {
+	case "rss":
+		sort.Slice(infos, func(i, j int) bool { return infos[i].RSSBytes > infos[j].RSSBytes })
+	case "iowait":
+		sort.Slice(infos, func(i, j int) bool {
+			if infos[i].IOWaitState != infos[j].IOWaitState {
+				return infos[i].IOWaitState
+			}
+			return infos[i].CPUPercent > infos[j].CPUPercent
+		})
+	default: // "cpu"
+		sort.Slice(infos, func(i, j int) bool { return infos[i].CPUPercent > infos[j].CPUPercent })
+	}
+}
+
+func containsState(states []string, want string) bool {
+	for _, s := range states {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
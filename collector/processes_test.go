@@ -0,0 +1,73 @@
+package collector
+
+import "testing"
+
+func TestSortProcessesByCPU(t *testing.T) {
+	infos := []ProcessInfo{
+		{PID: 1, CPUPercent: 10},
+		{PID: 2, CPUPercent: 90},
+		{PID: 3, CPUPercent: 50},
+	}
+	sortProcesses(infos, "cpu")
+	wantOrder := []int32{2, 3, 1}
+	for i, pid := range wantOrder {
+		if infos[i].PID != pid {
+			t.Fatalf("position %d: got pid %d, want %d (order %v)", i, infos[i].PID, pid, infos)
+		}
+	}
+}
+
+func TestSortProcessesByRSS(t *testing.T) {
+	infos := []ProcessInfo{
+		{PID: 1, RSSBytes: 1024},
+		{PID: 2, RSSBytes: 4096},
+		{PID: 3, RSSBytes: 2048},
+	}
+	sortProcesses(infos, "rss")
+	wantOrder := []int32{2, 3, 1}
+	for i, pid := range wantOrder {
+		if infos[i].PID != pid {
+			t.Fatalf("position %d: got pid %d, want %d (order %v)", i, infos[i].PID, pid, infos)
+		}
+	}
+}
+
+func TestSortProcessesByIOWait(t *testing.T) {
+	infos := []ProcessInfo{
+		{PID: 1, CPUPercent: 80, IOWaitState: false},
+		{PID: 2, CPUPercent: 10, IOWaitState: true},
+		{PID: 3, CPUPercent: 60, IOWaitState: true},
+	}
+	sortProcesses(infos, "iowait")
+	// IO-wait-blocked processes sort first regardless of CPU, then ties
+	// break by CPU descending.
+	wantOrder := []int32{3, 2, 1}
+	for i, pid := range wantOrder {
+		if infos[i].PID != pid {
+			t.Fatalf("position %d: got pid %d, want %d (order %v)", i, infos[i].PID, pid, infos)
+		}
+	}
+}
+
+func TestSortProcessesDefaultsToCPU(t *testing.T) {
+	infos := []ProcessInfo{
+		{PID: 1, CPUPercent: 5},
+		{PID: 2, CPUPercent: 95},
+	}
+	sortProcesses(infos, "bogus")
+	if infos[0].PID != 2 {
+		t.Fatalf("expected unknown sort mode to default to cpu descending, got %+v", infos)
+	}
+}
+
+func TestContainsState(t *testing.T) {
+	if !containsState([]string{"S", "D"}, "D") {
+		t.Fatalf("expected to find D in the state list")
+	}
+	if containsState([]string{"S", "R"}, "D") {
+		t.Fatalf("expected not to find D in the state list")
+	}
+	if containsState(nil, "D") {
+		t.Fatalf("expected no match against a nil state list")
+	}
+}
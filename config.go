@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resourceKind identifies which metric a ResourceRule applies to.
+type resourceKind string
+
+const (
+	resourceCPU    resourceKind = "cpu"
+	resourceMemory resourceKind = "memory"
+	resourceIOWait resourceKind = "iowait"
+	resourceDisk   resourceKind = "disk"
+)
+
+// ResourceRule configures warning and critical thresholds for one
+// monitored resource. Disk rules additionally carry the mount path they
+// watch; a Config may list several of them to watch more than one mount.
+type ResourceRule struct {
+	Kind     resourceKind `yaml:"kind"`
+	Path     string       `yaml:"path,omitempty"`
+	Warning  float64      `yaml:"warning"`
+	Critical float64      `yaml:"critical"`
+}
+
+// Name returns the human-readable resource label used in alert text and
+// as the alert-state dedupe key.
+func (r ResourceRule) Name() string {
+	if r.Kind == resourceDisk {
+		return fmt.Sprintf("Disk %s", r.Path)
+	}
+	return string(r.Kind)
+}
+
+// WebhookTarget is one webhook destination with its own auth header and
+// retry policy, allowing a Config to fan alerts out to more than one
+// endpoint.
+type WebhookTarget struct {
+	URL        string            `yaml:"url"`
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	MaxRetries int               `yaml:"maxRetries,omitempty"`
+}
+
+// Config is the structured configuration format loaded via -config. When
+// present it supersedes the flat CLI flags; flags remain as a fallback and
+// as overrides for fields a config file leaves unset.
+type Config struct {
+	Interval  time.Duration   `yaml:"interval,omitempty"`
+	Resources []ResourceRule  `yaml:"resources"`
+	Webhooks  []WebhookTarget `yaml:"webhooks,omitempty"`
+}
+
+// loadConfig reads and parses a YAML config file.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+	for _, r := range cfg.Resources {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("resource rule %q: %w", r.Name(), err)
+		}
+	}
+	return &cfg, nil
+}
+
+// validate checks that a ResourceRule's thresholds are sane: both within
+// (0, 100], disk rules carrying a mount path, and critical at or above
+// warning since severityFor assumes that ordering.
+func (r ResourceRule) validate() error {
+	if r.Kind == resourceDisk && r.Path == "" {
+		return fmt.Errorf("disk resource rule missing path")
+	}
+	if r.Warning <= 0 || r.Warning > 100 {
+		return fmt.Errorf("warning threshold must be within (0, 100], got %v", r.Warning)
+	}
+	if r.Critical <= 0 || r.Critical > 100 {
+		return fmt.Errorf("critical threshold must be within (0, 100], got %v", r.Critical)
+	}
+	if r.Critical < r.Warning {
+		return fmt.Errorf("critical threshold (%v) must be >= warning threshold (%v)", r.Critical, r.Warning)
+	}
+	return nil
+}
+
+// resourcesFromFlags builds the single-threshold ResourceRule set implied
+// by the flat CLI flags, used when -config is not supplied.
+func resourcesFromFlags(cfg *thresholdConfig) []ResourceRule {
+	rules := []ResourceRule{
+		{Kind: resourceCPU, Warning: cfg.cpuUsage, Critical: cfg.cpuUsage},
+		{Kind: resourceMemory, Warning: cfg.memUsage, Critical: cfg.memUsage},
+		{Kind: resourceDisk, Path: cfg.diskPath, Warning: cfg.diskUsage, Critical: cfg.diskUsage},
+	}
+	if cfg.ioWaitUsage > 0 {
+		rules = append(rules, ResourceRule{Kind: resourceIOWait, Warning: cfg.ioWaitUsage, Critical: cfg.ioWaitUsage})
+	}
+	return rules
+}
+
+// diskPaths returns the distinct mount paths the given rules watch.
+func diskPaths(rules []ResourceRule) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		if r.Kind == resourceDisk && !seen[r.Path] {
+			seen[r.Path] = true
+			paths = append(paths, r.Path)
+		}
+	}
+	return paths
+}
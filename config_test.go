@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResourceRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ResourceRule
+		wantErr bool
+	}{
+		{
+			name: "valid cpu rule",
+			rule: ResourceRule{Kind: resourceCPU, Warning: 70, Critical: 90},
+		},
+		{
+			name: "valid disk rule",
+			rule: ResourceRule{Kind: resourceDisk, Path: "/", Warning: 80, Critical: 95},
+		},
+		{
+			name:    "disk rule missing path",
+			rule:    ResourceRule{Kind: resourceDisk, Warning: 80, Critical: 95},
+			wantErr: true,
+		},
+		{
+			name:    "warning below range",
+			rule:    ResourceRule{Kind: resourceCPU, Warning: -5, Critical: 90},
+			wantErr: true,
+		},
+		{
+			name:    "warning above range",
+			rule:    ResourceRule{Kind: resourceCPU, Warning: 101, Critical: 101},
+			wantErr: true,
+		},
+		{
+			name:    "critical below range",
+			rule:    ResourceRule{Kind: resourceCPU, Warning: 10, Critical: -1},
+			wantErr: true,
+		},
+		{
+			name:    "critical above range",
+			rule:    ResourceRule{Kind: resourceCPU, Warning: 10, Critical: 101},
+			wantErr: true,
+		},
+		{
+			name:    "critical below warning",
+			rule:    ResourceRule{Kind: resourceCPU, Warning: 90, Critical: 70},
+			wantErr: true,
+		},
+		{
+			name: "critical equal to warning",
+			rule: ResourceRule{Kind: resourceCPU, Warning: 80, Critical: 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.validate()
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsInvalidRule(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/bad.yaml"
+	if err := os.WriteFile(path, []byte("resources:\n  - kind: cpu\n    warning: -5\n    critical: -1\n"), 0o644); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Fatalf("expected loadConfig to reject out-of-range thresholds")
+	}
+}
+
+func TestLoadConfigAcceptsValidRule(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/good.yaml"
+	if err := os.WriteFile(path, []byte("resources:\n  - kind: disk\n    path: /\n    warning: 80\n    critical: 95\n"), 0o644); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Resources) != 1 || cfg.Resources[0].Path != "/" {
+		t.Fatalf("unexpected parsed resources: %+v", cfg.Resources)
+	}
+}
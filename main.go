@@ -1,52 +1,52 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/wfunc/monitor/collector"
 )
 
 // thresholdConfig groups all alert thresholds.
 type thresholdConfig struct {
-	cpuUsage     float64
-	memUsage     float64
-	diskUsage    float64
-	diskPath     string
-	sampleEvery  time.Duration
-	webhookURL   string
-	serviceName  string
-	alertType    string
-	alertStatus  string
-	accountID    string
-	accountName  string
-	platform     string
-	httpClient   *http.Client
-	hostname     string
-	ioWaitUsage  float64
-	prevCPUTimes *cpu.TimesStat
-}
-
-// metricsSnapshot contains the collected metrics for a single sample.
-type metricsSnapshot struct {
-	cpuPercent    float64
-	memPercent    float64
-	diskPercent   float64
-	diskPath      string
-	ioWaitPercent float64
+	cpuUsage            float64
+	memUsage            float64
+	diskUsage           float64
+	diskPath            string
+	sampleEvery         time.Duration
+	webhookURL          string
+	serviceName         string
+	alertType           string
+	alertStatus         string
+	accountID           string
+	accountName         string
+	platform            string
+	httpClient          *http.Client
+	hostname            string
+	ioWaitUsage         float64
+	metricsListen       string
+	alertSink           string
+	sentryDSN           string
+	alertFileDir        string
+	alertFileMaxReports int
+	topN                int
+	topSort             string
+	forDuration         time.Duration
+	repeatInterval      time.Duration
+	hysteresis          float64
+	configPath          string
+	resources           []ResourceRule
+	webhookTargets      []WebhookTarget
+	alertSpoolPath      string
+	alertTTL            time.Duration
+	alertQueueCapacity  int
 }
 
 func main() {
@@ -59,7 +59,27 @@ func main() {
 		cfg.hostname = hostname
 	}
 
-	if cfg.webhookURL == "" {
+	if cfg.configPath != "" {
+		fileCfg, err := loadConfig(cfg.configPath)
+		if err != nil {
+			log.Fatalf("loading -config: %v", err)
+		}
+		cfg.resources = fileCfg.Resources
+		if len(cfg.resources) == 0 {
+			cfg.resources = resourcesFromFlags(&cfg)
+		}
+		cfg.webhookTargets = fileCfg.Webhooks
+		if fileCfg.Interval > 0 {
+			cfg.sampleEvery = fileCfg.Interval
+		}
+	} else {
+		cfg.resources = resourcesFromFlags(&cfg)
+	}
+	if len(cfg.webhookTargets) == 0 && cfg.webhookURL != "" {
+		cfg.webhookTargets = []WebhookTarget{{URL: cfg.webhookURL}}
+	}
+
+	if len(cfg.webhookTargets) == 0 {
 		log.Println("webhook URL not configured; alerts will not be sent to a remote endpoint")
 	}
 
@@ -75,7 +95,28 @@ func main() {
 		cancel()
 	}()
 
-	if err := monitor(ctx, &cfg); err != nil {
+	queue := newDeliveryQueue(cfg.alertQueueCapacity, cfg.alertSpoolPath, cfg.alertTTL)
+	if err := queue.replaySpool(); err != nil {
+		log.Printf("replaying alert spool: %v", err)
+	}
+	go queue.run(ctx, cfg.httpClient)
+
+	sinks, err := parseAlertSinks(cfg.alertSink, &cfg, queue)
+	if err != nil {
+		log.Fatalf("invalid -alert-sink: %v", err)
+	}
+
+	registry := newMetricsRegistry()
+	registry.setQueue(queue)
+	if cfg.metricsListen != "" {
+		log.Printf("serving Prometheus metrics on %s/metrics", cfg.metricsListen)
+		serveMetrics(cfg.metricsListen, registry)
+	}
+
+	engine := newAlertEngine(cfg.forDuration, cfg.repeatInterval, cfg.hysteresis, cfg.hostname)
+
+	source := collector.New(diskPaths(cfg.resources))
+	if err := monitor(ctx, &cfg, source, registry, sinks, engine); err != nil {
 		log.Fatalf("monitoring failed: %v", err)
 	}
 }
@@ -95,9 +136,29 @@ func parseFlags() thresholdConfig {
 	flag.StringVar(&cfg.accountID, "account-id", "", "Optional account identifier added to payload data.accountId")
 	flag.StringVar(&cfg.accountName, "account-name", "", "Optional account name added to payload data.accountName")
 	flag.StringVar(&cfg.platform, "platform", "system", "Platform value stored in payload data.platform")
+	flag.StringVar(&cfg.metricsListen, "metrics-listen", "", "Address to serve Prometheus metrics on, e.g. :9100 (disabled if empty)")
+	flag.StringVar(&cfg.alertSink, "alert-sink", "webhook", "Comma-separated alert destinations: webhook,sentry,stdout,file")
+	flag.StringVar(&cfg.sentryDSN, "sentry-dsn", "", "Sentry/Glitchtip DSN, required when -alert-sink includes sentry")
+	flag.StringVar(&cfg.alertFileDir, "alert-file-dir", "", "Directory to write rotated JSON alert reports, required when -alert-sink includes file")
+	flag.IntVar(&cfg.alertFileMaxReports, "alert-file-max-reports", 1000, "Maximum number of alert reports to retain in -alert-file-dir")
+	flag.IntVar(&cfg.topN, "top-n", 5, "Number of top offending processes to capture on an alert")
+	flag.StringVar(&cfg.topSort, "top-sort", "cpu", "Field to rank top offending processes by: cpu, rss or iowait")
+	flag.DurationVar(&cfg.forDuration, "for", 0, "Require a threshold to be breached for this long before firing (e.g. 30s)")
+	flag.DurationVar(&cfg.repeatInterval, "repeat-interval", 5*time.Minute, "Minimum time between repeat firing notifications for the same resource")
+	flag.Float64Var(&cfg.hysteresis, "hysteresis", 5, "Percentage points below threshold a metric must fall to before a resolved notification fires")
+	flag.StringVar(&cfg.configPath, "config", "", "Path to a YAML config file of resource rules and webhook targets; supersedes the flat threshold/webhook-url flags")
+	flag.StringVar(&cfg.alertSpoolPath, "alert-spool", "", "Path to a file spooling undelivered webhook payloads across restarts (disabled if empty)")
+	flag.DurationVar(&cfg.alertTTL, "alert-ttl", 24*time.Hour, "Maximum time to keep retrying a queued webhook delivery before dropping it")
+	flag.IntVar(&cfg.alertQueueCapacity, "alert-queue-capacity", 1000, "Maximum undelivered webhook payloads retained in memory for retry, oldest dropped first")
 	flag.Parse()
 	cfg.sampleEvery = *interval
 
+	switch cfg.topSort {
+	case "cpu", "rss", "iowait":
+	default:
+		log.Fatalf("top-sort must be one of cpu, rss, iowait, got %q", cfg.topSort)
+	}
+
 	if cfg.cpuUsage <= 0 || cfg.cpuUsage > 100 ||
 		cfg.memUsage <= 0 || cfg.memUsage > 100 ||
 		cfg.diskUsage <= 0 || cfg.diskUsage > 100 {
@@ -112,19 +173,40 @@ func parseFlags() thresholdConfig {
 		log.Fatal("interval must be greater than zero")
 	}
 
+	if cfg.forDuration < 0 {
+		log.Fatal("for must be >= 0")
+	}
+
+	if cfg.repeatInterval <= 0 {
+		log.Fatal("repeat-interval must be greater than zero")
+	}
+
+	if cfg.hysteresis < 0 {
+		log.Fatal("hysteresis must be >= 0")
+	}
+
+	if cfg.alertTTL <= 0 {
+		log.Fatal("alert-ttl must be greater than zero")
+	}
+
+	if cfg.alertQueueCapacity <= 0 {
+		log.Fatal("alert-queue-capacity must be greater than zero")
+	}
+
 	return cfg
 }
 
-func monitor(ctx context.Context, cfg *thresholdConfig) error {
+func monitor(ctx context.Context, cfg *thresholdConfig, source collector.Collector, registry *metricsRegistry, sinks []AlertSink, engine *alertEngine) error {
 	ticker := time.NewTicker(cfg.sampleEvery)
 	defer ticker.Stop()
 
-	fmt.Printf("Monitoring started: CPU>%0.1f%%, Mem>%0.1f%%, Disk(%s)>%0.1f%%, interval=%s\n",
-		cfg.cpuUsage, cfg.memUsage, cfg.diskPath, cfg.diskUsage, cfg.sampleEvery)
+	fmt.Printf("Monitoring started: %d resource rule(s), interval=%s\n", len(cfg.resources), cfg.sampleEvery)
+	for _, rule := range cfg.resources {
+		fmt.Printf("  - %s: warning>%0.1f%%, critical>%0.1f%%\n", rule.Name(), rule.Warning, rule.Critical)
+	}
 
-	// Prime CPU percent calculation; the first call with interval=0 returns 0.
-	if _, err := cpu.PercentWithContext(ctx, 0, false); err != nil {
-		return fmt.Errorf("priming CPU metrics: %w", err)
+	if err := source.Prime(ctx); err != nil {
+		return err
 	}
 
 	for {
@@ -132,181 +214,95 @@ func monitor(ctx context.Context, cfg *thresholdConfig) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
-			snapshot, err := collectMetrics(ctx, cfg)
+			snapshot, err := source.Collect(ctx)
 			if err != nil {
 				log.Printf("collecting metrics failed: %v", err)
 				continue
 			}
-			report(ctx, snapshot, cfg)
+			registry.observe(snapshot)
+			report(ctx, snapshot, cfg, registry, sinks, engine)
 		}
 	}
 }
 
-func collectMetrics(ctx context.Context, cfg *thresholdConfig) (metricsSnapshot, error) {
-	cpuPercent, err := currentCPUPercent(ctx)
-	if err != nil {
-		return metricsSnapshot{}, err
-	}
-
-	memStats, err := mem.VirtualMemoryWithContext(ctx)
-	if err != nil {
-		return metricsSnapshot{}, fmt.Errorf("fetching memory: %w", err)
-	}
-
-	diskStats, err := disk.UsageWithContext(ctx, cfg.diskPath)
-	if err != nil {
-		return metricsSnapshot{}, fmt.Errorf("fetching disk usage for %s: %w", cfg.diskPath, err)
-	}
-
-	ioWaitPercent, err := currentIOWaitPercent(ctx, cfg)
-	if err != nil {
-		log.Printf("fetching io wait percent failed: %v", err)
-		ioWaitPercent = 0
-	}
-
-	return metricsSnapshot{
-		cpuPercent:    round(cpuPercent, 1),
-		memPercent:    round(memStats.UsedPercent, 1),
-		diskPercent:   round(diskStats.UsedPercent, 1),
-		diskPath:      cfg.diskPath,
-		ioWaitPercent: round(ioWaitPercent, 1),
-	}, nil
-}
-
-func currentCPUPercent(ctx context.Context) (float64, error) {
-	values, err := cpu.PercentWithContext(ctx, 0, false)
-	if err != nil {
-		return 0, fmt.Errorf("fetching CPU percent: %w", err)
-	}
-	if len(values) == 0 {
-		return 0, fmt.Errorf("no CPU percentage data returned")
-	}
-	return values[0], nil
-}
-
-func currentIOWaitPercent(ctx context.Context, cfg *thresholdConfig) (float64, error) {
-	stats, err := cpu.TimesWithContext(ctx, false)
-	if err != nil {
-		return 0, fmt.Errorf("fetching CPU times: %w", err)
-	}
-	if len(stats) == 0 {
-		return 0, fmt.Errorf("no CPU times returned")
-	}
-
-	current := stats[0]
-	if cfg.prevCPUTimes == nil {
-		cfg.prevCPUTimes = &cpu.TimesStat{}
-		*cfg.prevCPUTimes = current
-		return 0, nil
-	}
-
-	prev := *cfg.prevCPUTimes
-	totalDelta := current.Total() - prev.Total()
-	if totalDelta <= 0 {
-		*cfg.prevCPUTimes = current
-		return 0, nil
-	}
+func report(ctx context.Context, snapshot collector.Snapshot, cfg *thresholdConfig, registry *metricsRegistry, sinks []AlertSink, engine *alertEngine) {
+	timestamp := time.Now().Format(time.RFC3339)
 
-	ioWaitDelta := current.Iowait - prev.Iowait
-	*cfg.prevCPUTimes = current
-	if ioWaitDelta <= 0 {
-		return 0, nil
+	fmt.Printf("[%s] CPU: %5.1f%% | MEM: %5.1f%% | IOWAIT: %5.1f%%", timestamp, snapshot.CPUPercent, snapshot.MemPercent, snapshot.IOWaitPercent)
+	for _, d := range snapshot.Disks {
+		fmt.Printf(" | DISK(%s): %5.1f%%", d.Path, d.Percent)
 	}
+	fmt.Println()
 
-	percent := (ioWaitDelta / totalDelta) * 100
-	if percent < 0 {
-		return 0, nil
-	}
-	if percent > 100 {
-		percent = 100
+	for _, rule := range cfg.resources {
+		actual, ok := snapshotValue(snapshot, rule)
+		if !ok {
+			continue
+		}
+		evaluateResource(ctx, cfg, registry, sinks, engine, rule, actual)
 	}
-	return percent, nil
 }
 
-func report(ctx context.Context, snapshot metricsSnapshot, cfg *thresholdConfig) {
-	timestamp := time.Now().Format(time.RFC3339)
-
-	fmt.Printf("[%s] CPU: %5.1f%% | MEM: %5.1f%% | DISK(%s): %5.1f%% | IOWAIT: %5.1f%%\n",
-		timestamp, snapshot.cpuPercent, snapshot.memPercent, snapshot.diskPath, snapshot.diskPercent, snapshot.ioWaitPercent)
-
-	if snapshot.cpuPercent > cfg.cpuUsage {
-		triggerAlert(ctx, cfg, "CPU", snapshot.cpuPercent, cfg.cpuUsage)
-	}
-	if snapshot.memPercent > cfg.memUsage {
-		triggerAlert(ctx, cfg, "Memory", snapshot.memPercent, cfg.memUsage)
-	}
-	if snapshot.diskPercent > cfg.diskUsage {
-		triggerAlert(ctx, cfg, fmt.Sprintf("Disk %s", snapshot.diskPath), snapshot.diskPercent, cfg.diskUsage)
-	}
-	if cfg.ioWaitUsage > 0 && snapshot.ioWaitPercent > cfg.ioWaitUsage {
-		triggerAlert(ctx, cfg, "IO Wait", snapshot.ioWaitPercent, cfg.ioWaitUsage)
+// snapshotValue picks the sample value a ResourceRule applies to.
+func snapshotValue(snapshot collector.Snapshot, rule ResourceRule) (float64, bool) {
+	switch rule.Kind {
+	case resourceCPU:
+		return snapshot.CPUPercent, true
+	case resourceMemory:
+		return snapshot.MemPercent, true
+	case resourceIOWait:
+		return snapshot.IOWaitPercent, true
+	case resourceDisk:
+		for _, d := range snapshot.Disks {
+			if d.Path == rule.Path {
+				return d.Percent, true
+			}
+		}
 	}
+	return 0, false
 }
 
-func triggerAlert(ctx context.Context, cfg *thresholdConfig, resource string, actual, threshold float64) {
-	reason := fmt.Sprintf("%s usage %.1f%% exceeds threshold %.1f%%", resource, actual, threshold)
-	fmt.Printf("ALERT: %s\n", reason)
-	sendWebhook(ctx, cfg, resource, actual, threshold, reason)
-}
-
-func round(value float64, precision int) float64 {
-	factor := math.Pow(10, float64(precision))
-	return math.Round(value*factor) / factor
-}
-
-func sendWebhook(ctx context.Context, cfg *thresholdConfig, resource string, actual, threshold float64, reason string) {
-	if cfg.webhookURL == "" || cfg.httpClient == nil {
-		return
-	}
-
-	timestamp := time.Now().Format(time.RFC3339)
-	data := map[string]any{
-		"resource":  resource,
-		"actual":    round(actual, 2),
-		"threshold": round(threshold, 2),
-		"status":    cfg.alertStatus,
-		"reason":    reason,
-		"platform":  cfg.platform,
-		"host":      cfg.hostname,
-		"timestamp": timestamp,
-	}
-	if cfg.accountID != "" {
-		data["accountId"] = cfg.accountID
-	}
-	if cfg.accountName != "" {
-		data["accountName"] = cfg.accountName
-	}
-
-	payload := map[string]any{
-		"type":      cfg.alertType,
-		"service":   cfg.serviceName,
-		"timestamp": timestamp,
-		"data":      data,
-	}
-
-	reqBody, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("failed to marshal webhook payload: %v", err)
+// evaluateResource runs one resource's sample through the alert state
+// machine and, if it produced a firing or resolved transition, dispatches
+// it to the configured sinks.
+func evaluateResource(ctx context.Context, cfg *thresholdConfig, registry *metricsRegistry, sinks []AlertSink, engine *alertEngine, rule ResourceRule, actual float64) {
+	transition := engine.evaluate(rule, actual, cfg.alertStatus, time.Now())
+	if transition == nil {
 		return
 	}
+	triggerAlert(ctx, cfg, registry, sinks, rule.Name(), actual, rule.Warning, transition)
+}
 
-	fmt.Printf("Webhook Payload: %+v\n", payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.webhookURL, bytes.NewReader(reqBody))
-	if err != nil {
-		log.Printf("failed to build webhook request: %v", err)
-		return
+func triggerAlert(ctx context.Context, cfg *thresholdConfig, registry *metricsRegistry, sinks []AlertSink, resource string, actual, threshold float64, transition *stateTransition) {
+	var reason string
+	if transition.Status == "resolved" {
+		reason = fmt.Sprintf("%s usage %.1f%% recovered below threshold %.1f%%", resource, actual, threshold)
+	} else {
+		reason = fmt.Sprintf("%s usage %.1f%% exceeds threshold %.1f%%", resource, actual, threshold)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	fmt.Printf("ALERT[%s/%s]: %s\n", transition.Status, transition.Severity, reason)
+	registry.incAlert(resource)
 
-	resp, err := cfg.httpClient.Do(req)
-	if err != nil {
-		log.Printf("webhook request failed: %v", err)
-		return
+	var topProcesses []collector.ProcessInfo
+	if transition.Status == "firing" {
+		var err error
+		topProcesses, err = collector.CollectTopProcesses(ctx, cfg.topN, cfg.topSort)
+		if err != nil {
+			log.Printf("collecting top processes for %s alert failed: %v", resource, err)
+		}
 	}
-	defer resp.Body.Close()
-	_, _ = io.Copy(io.Discard, resp.Body)
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("webhook responded with status %s", resp.Status)
-	}
+	dispatchAlert(ctx, cfg, sinks, Alert{
+		Resource:     resource,
+		Actual:       actual,
+		Threshold:    threshold,
+		Reason:       reason,
+		Timestamp:    time.Now(),
+		TopProcesses: topProcesses,
+		State:        transition.Status,
+		Severity:     transition.Severity,
+		FirstSeen:    transition.FirstSeen,
+		FiredAt:      transition.FiredAt,
+		Fingerprint:  transition.Fingerprint,
+	})
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/wfunc/monitor/collector"
+)
+
+// metricsRegistry holds the latest snapshot and alert counters so they can
+// be exposed in Prometheus text format alongside the push-based webhook
+// path. All methods are safe for concurrent use.
+type metricsRegistry struct {
+	mu         sync.Mutex
+	have       bool
+	snapshot   collector.Snapshot
+	alertTotal map[string]float64
+	queue      *deliveryQueue
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{alertTotal: make(map[string]float64)}
+}
+
+// setQueue attaches the webhook retry queue whose counters should be
+// rendered alongside the sample metrics. Safe to call before serving
+// starts; queue is read under m.mu so this may also be called afterward.
+func (m *metricsRegistry) setQueue(queue *deliveryQueue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = queue
+}
+
+func (m *metricsRegistry) observe(snapshot collector.Snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.have = true
+	m.snapshot = snapshot
+}
+
+func (m *metricsRegistry) incAlert(resource string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alertTotal[resource]++
+}
+
+// ServeHTTP renders the current metrics in Prometheus text exposition
+// format.
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if !m.have {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP system_monitor_cpu_percent Current CPU usage percentage.\n")
+	fmt.Fprintf(w, "# TYPE system_monitor_cpu_percent gauge\n")
+	fmt.Fprintf(w, "system_monitor_cpu_percent %g\n", m.snapshot.CPUPercent)
+
+	fmt.Fprintf(w, "# HELP system_monitor_mem_percent Current memory usage percentage.\n")
+	fmt.Fprintf(w, "# TYPE system_monitor_mem_percent gauge\n")
+	fmt.Fprintf(w, "system_monitor_mem_percent %g\n", m.snapshot.MemPercent)
+
+	fmt.Fprintf(w, "# HELP system_monitor_disk_percent Current disk usage percentage.\n")
+	fmt.Fprintf(w, "# TYPE system_monitor_disk_percent gauge\n")
+	for _, d := range m.snapshot.Disks {
+		fmt.Fprintf(w, "system_monitor_disk_percent{path=%q} %g\n", d.Path, d.Percent)
+	}
+
+	fmt.Fprintf(w, "# HELP system_monitor_iowait_percent Current IO-wait percentage.\n")
+	fmt.Fprintf(w, "# TYPE system_monitor_iowait_percent gauge\n")
+	fmt.Fprintf(w, "system_monitor_iowait_percent %g\n", m.snapshot.IOWaitPercent)
+
+	fmt.Fprintf(w, "# HELP system_monitor_alerts_total Total number of alert notifications (firing and resolved) per resource.\n")
+	fmt.Fprintf(w, "# TYPE system_monitor_alerts_total counter\n")
+	for resource, count := range m.alertTotal {
+		fmt.Fprintf(w, "system_monitor_alerts_total{resource=%q} %g\n", resource, count)
+	}
+
+	if m.queue != nil {
+		delivered, retried, dropped, depth := m.queue.stats()
+
+		fmt.Fprintf(w, "# HELP system_monitor_webhook_delivered_total Webhook deliveries that eventually succeeded after queuing.\n")
+		fmt.Fprintf(w, "# TYPE system_monitor_webhook_delivered_total counter\n")
+		fmt.Fprintf(w, "system_monitor_webhook_delivered_total %d\n", delivered)
+
+		fmt.Fprintf(w, "# HELP system_monitor_webhook_retried_total Queued webhook delivery attempts that were retried.\n")
+		fmt.Fprintf(w, "# TYPE system_monitor_webhook_retried_total counter\n")
+		fmt.Fprintf(w, "system_monitor_webhook_retried_total %d\n", retried)
+
+		fmt.Fprintf(w, "# HELP system_monitor_webhook_dropped_total Queued webhook deliveries dropped after exceeding their TTL or the queue capacity.\n")
+		fmt.Fprintf(w, "# TYPE system_monitor_webhook_dropped_total counter\n")
+		fmt.Fprintf(w, "system_monitor_webhook_dropped_total %d\n", dropped)
+
+		fmt.Fprintf(w, "# HELP system_monitor_webhook_queue_depth Webhook deliveries currently queued for retry.\n")
+		fmt.Fprintf(w, "# TYPE system_monitor_webhook_queue_depth gauge\n")
+		fmt.Fprintf(w, "system_monitor_webhook_queue_depth %d\n", depth)
+	}
+}
+
+// serveMetrics starts the Prometheus scrape endpoint in the background. It
+// does not block; listen errors are logged and terminate the process since
+// a requested metrics listener that fails to bind is a startup error.
+func serveMetrics(addr string, registry *metricsRegistry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatalf("metrics listener failed: %v", err)
+		}
+	}()
+}
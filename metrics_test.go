@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wfunc/monitor/collector"
+)
+
+func TestMetricsRegistryServeHTTPBeforeFirstSample(t *testing.T) {
+	registry := newMetricsRegistry()
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty body before any sample is observed, got %q", rec.Body.String())
+	}
+}
+
+func TestMetricsRegistryServeHTTPRendersSample(t *testing.T) {
+	registry := newMetricsRegistry()
+	registry.observe(collector.Snapshot{
+		CPUPercent:    42.5,
+		MemPercent:    61.2,
+		IOWaitPercent: 3.1,
+		Disks:         []collector.DiskUsage{{Path: "/", Percent: 55.5}},
+	})
+	registry.incAlert("cpu")
+	registry.incAlert("cpu")
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"system_monitor_cpu_percent 42.5",
+		"system_monitor_mem_percent 61.2",
+		`system_monitor_disk_percent{path="/"} 55.5`,
+		"system_monitor_iowait_percent 3.1",
+		`system_monitor_alerts_total{resource="cpu"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected metrics output to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsRegistryServeHTTPOmitsQueueStatsWhenUnset(t *testing.T) {
+	registry := newMetricsRegistry()
+	registry.observe(collector.Snapshot{})
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "system_monitor_webhook_") {
+		t.Fatalf("expected no webhook queue metrics when no queue is attached, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestMetricsRegistryServeHTTPRendersQueueStats(t *testing.T) {
+	registry := newMetricsRegistry()
+	registry.observe(collector.Snapshot{})
+	queue := newDeliveryQueue(10, "", 0)
+	queue.enqueue(WebhookTarget{URL: "http://example.invalid"}, []byte(`{}`))
+	registry.setQueue(queue)
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"system_monitor_webhook_delivered_total 0",
+		"system_monitor_webhook_retried_total 0",
+		"system_monitor_webhook_dropped_total 0",
+		"system_monitor_webhook_queue_depth 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected metrics output to contain %q, got:\n%s", want, body)
+		}
+	}
+}
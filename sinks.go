@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wfunc/monitor/collector"
+)
+
+// Alert is the resource-threshold breach passed to every configured
+// AlertSink. It carries everything a sink needs to render its own
+// representation without reaching back into thresholdConfig.
+type Alert struct {
+	Resource     string
+	Actual       float64
+	Threshold    float64
+	Reason       string
+	Timestamp    time.Time
+	TopProcesses []collector.ProcessInfo
+	State        string // "firing" or "resolved", from the alert state machine
+	Severity     string // "warning" or "critical"; the -alert-status value for single-threshold rules
+	FirstSeen    time.Time
+	FiredAt      time.Time
+	Fingerprint  string
+}
+
+// topProcessesPayload renders the top offending processes in the shape
+// every sink's JSON payload embeds under data.topProcesses.
+func topProcessesPayload(procs []collector.ProcessInfo) []map[string]any {
+	if len(procs) == 0 {
+		return nil
+	}
+	out := make([]map[string]any, 0, len(procs))
+	for _, p := range procs {
+		out = append(out, map[string]any{
+			"pid":        p.PID,
+			"name":       p.Name,
+			"cmdline":    p.Cmdline,
+			"cpuPercent": p.CPUPercent,
+			"rssBytes":   p.RSSBytes,
+			"ioWait":     p.IOWaitState,
+		})
+	}
+	return out
+}
+
+// AlertSink delivers an Alert to some destination. Implementations should
+// not panic; Send errors are logged by the caller and do not stop delivery
+// to the other configured sinks.
+type AlertSink interface {
+	Name() string
+	Send(ctx context.Context, cfg *thresholdConfig, alert Alert) error
+}
+
+// dispatchAlert fans an alert out to every configured sink, logging but not
+// stopping on individual sink failures.
+func dispatchAlert(ctx context.Context, cfg *thresholdConfig, sinks []AlertSink, alert Alert) {
+	for _, sink := range sinks {
+		if err := sink.Send(ctx, cfg, alert); err != nil {
+			log.Printf("alert sink %q failed: %v", sink.Name(), err)
+		}
+	}
+}
+
+// parseAlertSinks builds the configured sinks from a comma-separated list
+// such as "webhook,sentry,stdout,file". queue backs the webhook sink's
+// deliveries that fail their immediate retries.
+func parseAlertSinks(spec string, cfg *thresholdConfig, queue *deliveryQueue) ([]AlertSink, error) {
+	var sinks []AlertSink
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		switch name {
+		case "webhook":
+			sinks = append(sinks, &webhookSink{queue: queue})
+		case "sentry":
+			if cfg.sentryDSN == "" {
+				return nil, fmt.Errorf("alert-sink %q requires -sentry-dsn", name)
+			}
+			sinks = append(sinks, &sentrySink{})
+		case "stdout":
+			sinks = append(sinks, &stdoutSink{})
+		case "file":
+			if cfg.alertFileDir == "" {
+				return nil, fmt.Errorf("alert-sink %q requires -alert-file-dir", name)
+			}
+			sinks = append(sinks, &fileSink{})
+		default:
+			return nil, fmt.Errorf("unknown alert sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// webhookSink posts the alert as the original account-anomaly style
+// payload this tool has always sent. Deliveries that fail their immediate
+// retries are handed to queue, if set, for background retry with
+// exponential backoff instead of being dropped.
+type webhookSink struct {
+	queue *deliveryQueue
+}
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+func (s *webhookSink) Send(ctx context.Context, cfg *thresholdConfig, alert Alert) error {
+	if len(cfg.webhookTargets) == 0 || cfg.httpClient == nil {
+		return nil
+	}
+
+	timestamp := alert.Timestamp.Format(time.RFC3339)
+	status := alert.Severity
+	if status == "" {
+		status = cfg.alertStatus
+	}
+	data := map[string]any{
+		"resource":    alert.Resource,
+		"actual":      collector.Round(alert.Actual, 2),
+		"threshold":   collector.Round(alert.Threshold, 2),
+		"status":      status,
+		"reason":      alert.Reason,
+		"platform":    cfg.platform,
+		"host":        cfg.hostname,
+		"timestamp":   timestamp,
+		"state":       alert.State,
+		"firstSeen":   alert.FirstSeen.Format(time.RFC3339),
+		"firedAt":     alert.FiredAt.Format(time.RFC3339),
+		"fingerprint": alert.Fingerprint,
+	}
+	if cfg.accountID != "" {
+		data["accountId"] = cfg.accountID
+	}
+	if cfg.accountName != "" {
+		data["accountName"] = cfg.accountName
+	}
+	if procs := topProcessesPayload(alert.TopProcesses); procs != nil {
+		data["topProcesses"] = procs
+	}
+
+	payload := map[string]any{
+		"type":      cfg.alertType,
+		"service":   cfg.serviceName,
+		"timestamp": timestamp,
+		"data":      data,
+	}
+
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling webhook payload: %w", err)
+	}
+	fmt.Printf("Webhook Payload: %+v\n", payload)
+
+	var firstErr error
+	for _, target := range cfg.webhookTargets {
+		if err := s.post(ctx, cfg, target, reqBody); err != nil {
+			err = fmt.Errorf("posting to %s: %w", target.URL, err)
+			log.Print(err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if s.queue != nil {
+				s.queue.enqueue(target, reqBody)
+			}
+		}
+	}
+	return firstErr
+}
+
+// post delivers reqBody to a single webhook target, retrying immediately
+// up to target.MaxRetries times on network or non-2xx failures.
+func (s *webhookSink) post(ctx context.Context, cfg *thresholdConfig, target WebhookTarget, reqBody []byte) error {
+	attempts := target.MaxRetries + 1
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(reqBody))
+		if err != nil {
+			return fmt.Errorf("building webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range target.Headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := cfg.httpClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("webhook request failed: %w", err)
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return lastErr
+}
+
+// sentrySink packages the alert as a Sentry/Glitchtip-compatible event
+// envelope and POSTs it to the configured DSN's store endpoint, so alerts
+// show up alongside application errors without a separate webhook bridge.
+type sentrySink struct{}
+
+func (s *sentrySink) Name() string { return "sentry" }
+
+func (s *sentrySink) Send(ctx context.Context, cfg *thresholdConfig, alert Alert) error {
+	endpoint, err := sentryStoreEndpoint(cfg.sentryDSN)
+	if err != nil {
+		return fmt.Errorf("parsing sentry dsn: %w", err)
+	}
+
+	tags := map[string]string{"host": cfg.hostname, "state": alert.State, "fingerprint": alert.Fingerprint}
+	if cfg.accountID != "" {
+		tags["account_id"] = cfg.accountID
+	}
+	if cfg.accountName != "" {
+		tags["account_name"] = cfg.accountName
+	}
+
+	severity := alert.Severity
+	if severity == "" {
+		severity = cfg.alertStatus
+	}
+
+	event := map[string]any{
+		"event_id":    newSentryEventID(),
+		"timestamp":   alert.Timestamp.UTC().Format(time.RFC3339),
+		"level":       sentryLevel(severity),
+		"server_name": cfg.hostname,
+		"tags":        tags,
+		"message":     map[string]any{"formatted": alert.Reason},
+		"extra": map[string]any{
+			"resource":     alert.Resource,
+			"actual":       collector.Round(alert.Actual, 2),
+			"threshold":    collector.Round(alert.Threshold, 2),
+			"topProcesses": topProcessesPayload(alert.TopProcesses),
+			"firstSeen":    alert.FirstSeen.Format(time.RFC3339),
+			"firedAt":      alert.FiredAt.Format(time.RFC3339),
+		},
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling sentry event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cfg.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+// sentryStoreEndpoint turns a DSN of the form
+// "https://<key>@<host>/<project>" into its event store URL.
+func sentryStoreEndpoint(dsn string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", fmt.Errorf("dsn missing public key")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return "", fmt.Errorf("dsn missing project id")
+	}
+	key := u.User.Username()
+	u.User = nil
+	u.Path = fmt.Sprintf("/api/%s/store/", project)
+	u.RawQuery = "sentry_key=" + key + "&sentry_version=7"
+	return u.String(), nil
+}
+
+func sentryLevel(alertStatus string) string {
+	switch alertStatus {
+	case "critical":
+		return "error"
+	case "warning":
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func newSentryEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "00000000000000000000000000000000"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// stdoutSink prints the full alert to stdout, useful for local debugging
+// or piping into another log collector.
+type stdoutSink struct{}
+
+func (s *stdoutSink) Name() string { return "stdout" }
+
+func (s *stdoutSink) Send(_ context.Context, cfg *thresholdConfig, alert Alert) error {
+	status := alert.Severity
+	if status == "" {
+		status = cfg.alertStatus
+	}
+	fmt.Printf("[stdout-sink] %s resource=%s state=%s actual=%.2f threshold=%.2f status=%s fingerprint=%s topProcesses=%d\n",
+		alert.Timestamp.Format(time.RFC3339), alert.Resource, alert.State, alert.Actual, alert.Threshold, status, alert.Fingerprint, len(alert.TopProcesses))
+	return nil
+}
+
+// fileSink rotates JSON alert reports into a directory, mirroring how
+// crash-receivers store individual failure reports as one file each.
+type fileSink struct{}
+
+func (s *fileSink) Name() string { return "file" }
+
+func (s *fileSink) Send(_ context.Context, cfg *thresholdConfig, alert Alert) error {
+	if err := os.MkdirAll(cfg.alertFileDir, 0o755); err != nil {
+		return fmt.Errorf("creating alert file dir: %w", err)
+	}
+
+	status := alert.Severity
+	if status == "" {
+		status = cfg.alertStatus
+	}
+	report := map[string]any{
+		"resource":    alert.Resource,
+		"actual":      collector.Round(alert.Actual, 2),
+		"threshold":   collector.Round(alert.Threshold, 2),
+		"reason":      alert.Reason,
+		"status":      status,
+		"host":        cfg.hostname,
+		"timestamp":   alert.Timestamp.Format(time.RFC3339),
+		"state":       alert.State,
+		"firstSeen":   alert.FirstSeen.Format(time.RFC3339),
+		"firedAt":     alert.FiredAt.Format(time.RFC3339),
+		"fingerprint": alert.Fingerprint,
+	}
+	if procs := topProcessesPayload(alert.TopProcesses); procs != nil {
+		report["topProcesses"] = procs
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling alert report: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.json", alert.Timestamp.UTC().Format("20060102T150405.000000000"), sanitizeFileName(alert.Resource))
+	path := filepath.Join(cfg.alertFileDir, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return fmt.Errorf("writing alert report: %w", err)
+	}
+	return rotateAlertFiles(cfg.alertFileDir, cfg.alertFileMaxReports)
+}
+
+// rotateAlertFiles removes the oldest *.json reports once the directory
+// holds more than maxReports of them.
+func rotateAlertFiles(dir string, maxReports int) error {
+	if maxReports <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading alert file dir: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	excess := len(names) - maxReports
+	for i := 0; i < excess; i++ {
+		if err := os.Remove(filepath.Join(dir, names[i])); err != nil {
+			return fmt.Errorf("removing rotated alert report: %w", err)
+		}
+	}
+	return nil
+}
+
+func sanitizeFileName(s string) string {
+	return strings.NewReplacer(" ", "_", "/", "_").Replace(s)
+}
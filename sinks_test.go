@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestSentryStoreEndpoint(t *testing.T) {
+	endpoint, err := sentryStoreEndpoint("https://publickey@sentry.example.com/7")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "https://sentry.example.com/api/7/store/?sentry_key=publickey&sentry_version=7"
+	if endpoint != want {
+		t.Fatalf("got %q, want %q", endpoint, want)
+	}
+}
+
+func TestSentryStoreEndpointMissingKey(t *testing.T) {
+	if _, err := sentryStoreEndpoint("https://sentry.example.com/7"); err == nil {
+		t.Fatalf("expected an error for a dsn missing its public key")
+	}
+}
+
+func TestSentryStoreEndpointMissingProject(t *testing.T) {
+	if _, err := sentryStoreEndpoint("https://publickey@sentry.example.com/"); err == nil {
+		t.Fatalf("expected an error for a dsn missing its project id")
+	}
+}
+
+func TestSentryStoreEndpointMalformedDSN(t *testing.T) {
+	if _, err := sentryStoreEndpoint("://not-a-url"); err == nil {
+		t.Fatalf("expected an error for a malformed dsn")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// backoffBase and backoffCap bound the exponential backoff applied
+	// between retries of a queued delivery; the actual wait is a full
+	// jitter draw between 0 and min(backoffCap, backoffBase*2^(attempts-1)).
+	backoffBase = 1 * time.Second
+	backoffCap  = 5 * time.Minute
+
+	// queuePollInterval is how often the background goroutine checks the
+	// queue for deliveries that have come due.
+	queuePollInterval = 500 * time.Millisecond
+)
+
+// queuedDelivery is one webhook POST that failed its initial attempts and
+// is waiting for a background retry.
+type queuedDelivery struct {
+	ID          uint64          `json:"id"`
+	Target      WebhookTarget   `json:"target"`
+	Body        json.RawMessage `json:"body"`
+	Enqueued    time.Time       `json:"enqueued"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+}
+
+// deliveryQueue is a bounded, optionally spooled retry queue for webhook
+// deliveries that failed their initial attempts. A single background
+// goroutine drains it with exponential backoff and full jitter, so a
+// webhook outage backs up alerts instead of losing them. Items older than
+// ttl are dropped instead of retried forever, and the in-memory queue is
+// capped at capacity, oldest entry evicted first, so a prolonged outage
+// can't grow the process's memory without bound.
+type deliveryQueue struct {
+	mu        sync.Mutex
+	items     []*queuedDelivery
+	nextID    uint64
+	capacity  int
+	spoolPath string
+	ttl       time.Duration
+
+	delivered uint64
+	retried   uint64
+	dropped   uint64
+}
+
+func newDeliveryQueue(capacity int, spoolPath string, ttl time.Duration) *deliveryQueue {
+	return &deliveryQueue{capacity: capacity, spoolPath: spoolPath, ttl: ttl}
+}
+
+// enqueue accepts a delivery for background retry, evicting the oldest
+// queued item if the queue is already at capacity.
+func (q *deliveryQueue) enqueue(target WebhookTarget, body []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	q.items = append(q.items, &queuedDelivery{
+		ID:       q.nextID,
+		Target:   target,
+		Body:     append(json.RawMessage(nil), body...),
+		Enqueued: time.Now(),
+	})
+	if q.capacity > 0 && len(q.items) > q.capacity {
+		q.items = q.items[1:]
+		atomic.AddUint64(&q.dropped, 1)
+	}
+	q.persistLocked()
+}
+
+// replaySpool loads any deliveries persisted by a previous run and
+// re-queues the ones that haven't exceeded their TTL. It must be called
+// once at startup before run begins draining the queue.
+func (q *deliveryQueue) replaySpool() error {
+	if q.spoolPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(q.spoolPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading alert spool: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var item queuedDelivery
+		if err := json.Unmarshal(line, &item); err != nil {
+			log.Printf("skipping malformed spooled alert: %v", err)
+			continue
+		}
+		if now.Sub(item.Enqueued) > q.ttl {
+			atomic.AddUint64(&q.dropped, 1)
+			continue
+		}
+		if item.ID > q.nextID {
+			q.nextID = item.ID
+		}
+		stored := item
+		q.items = append(q.items, &stored)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanning alert spool: %w", err)
+	}
+
+	q.persistLocked()
+	return nil
+}
+
+// run drains the queue until ctx is cancelled, retrying due deliveries
+// with the given client.
+func (q *deliveryQueue) run(ctx context.Context, client *http.Client) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.processDue(client)
+		}
+	}
+}
+
+// processDue attempts delivery of at most one due item per call; the
+// queuePollInterval tick rate bounds how quickly the queue drains.
+func (q *deliveryQueue) processDue(client *http.Client) {
+	item := q.pickDue()
+	if item == nil {
+		return
+	}
+
+	if time.Since(item.Enqueued) > q.ttl {
+		q.removeAndCount(item.ID, &q.dropped)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, item.Target.URL, bytes.NewReader(item.Body))
+	if err != nil {
+		log.Printf("dropping queued alert for %s: %v", item.Target.URL, err)
+		q.removeAndCount(item.ID, &q.dropped)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range item.Target.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		q.removeAndCount(item.ID, &q.delivered)
+		return
+	}
+	q.scheduleRetry(item.ID)
+}
+
+// pickDue returns the first queued item whose NextAttempt has arrived, or
+// nil if none are due yet.
+func (q *deliveryQueue) pickDue() *queuedDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	for _, item := range q.items {
+		if !item.NextAttempt.After(now) {
+			return item
+		}
+	}
+	return nil
+}
+
+func (q *deliveryQueue) scheduleRetry(id uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.items {
+		if item.ID == id {
+			item.Attempts++
+			item.NextAttempt = time.Now().Add(backoffWithJitter(item.Attempts))
+			break
+		}
+	}
+	atomic.AddUint64(&q.retried, 1)
+	q.persistLocked()
+}
+
+func (q *deliveryQueue) removeAndCount(id uint64, counter *uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			break
+		}
+	}
+	atomic.AddUint64(counter, 1)
+	q.persistLocked()
+}
+
+// stats returns the queue's delivery counters and current depth for the
+// metrics endpoint.
+func (q *deliveryQueue) stats() (delivered, retried, dropped uint64, depth int) {
+	q.mu.Lock()
+	depth = len(q.items)
+	q.mu.Unlock()
+	return atomic.LoadUint64(&q.delivered), atomic.LoadUint64(&q.retried), atomic.LoadUint64(&q.dropped), depth
+}
+
+// persistLocked rewrites the spool file from the current queue contents.
+// Callers must hold q.mu. A temp-file-then-rename is used so a crash
+// mid-write can't leave a truncated spool behind.
+func (q *deliveryQueue) persistLocked() {
+	if q.spoolPath == "" {
+		return
+	}
+	tmp := q.spoolPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Printf("writing alert spool: %v", err)
+		return
+	}
+
+	w := bufio.NewWriter(f)
+	for _, item := range q.items {
+		line, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("writing alert spool: %v", err)
+		f.Close()
+		return
+	}
+	if err := f.Close(); err != nil {
+		log.Printf("writing alert spool: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, q.spoolPath); err != nil {
+		log.Printf("writing alert spool: %v", err)
+	}
+}
+
+// backoffWithJitter implements full-jitter exponential backoff: a random
+// draw between 0 and min(backoffCap, backoffBase*2^(attempts-1)).
+func backoffWithJitter(attempts int) time.Duration {
+	d := float64(backoffBase) * math.Pow(2, float64(attempts-1))
+	if d > float64(backoffCap) || d <= 0 {
+		d = float64(backoffCap)
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeliveryQueueEnqueueAndCapacityEviction(t *testing.T) {
+	q := newDeliveryQueue(2, "", time.Hour)
+
+	q.enqueue(WebhookTarget{URL: "http://example.invalid/1"}, []byte(`{}`))
+	q.enqueue(WebhookTarget{URL: "http://example.invalid/2"}, []byte(`{}`))
+	if _, _, _, depth := q.stats(); depth != 2 {
+		t.Fatalf("expected depth 2, got %d", depth)
+	}
+
+	// A third enqueue past capacity should evict the oldest entry.
+	q.enqueue(WebhookTarget{URL: "http://example.invalid/3"}, []byte(`{}`))
+	_, _, dropped, depth := q.stats()
+	if depth != 2 {
+		t.Fatalf("expected depth to stay capped at 2, got %d", depth)
+	}
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped from capacity eviction, got %d", dropped)
+	}
+	if q.items[0].Target.URL != "http://example.invalid/2" {
+		t.Fatalf("expected the oldest entry to be evicted, queue head is %s", q.items[0].Target.URL)
+	}
+}
+
+func TestDeliveryQueueProcessDueDelivers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	q := newDeliveryQueue(10, "", time.Hour)
+	q.enqueue(WebhookTarget{URL: srv.URL}, []byte(`{}`))
+
+	q.processDue(srv.Client())
+
+	delivered, _, _, depth := q.stats()
+	if delivered != 1 {
+		t.Fatalf("expected 1 delivered, got %d", delivered)
+	}
+	if depth != 0 {
+		t.Fatalf("expected the queue to drain after a successful delivery, depth=%d", depth)
+	}
+}
+
+func TestDeliveryQueueProcessDueRetriesOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	q := newDeliveryQueue(10, "", time.Hour)
+	q.enqueue(WebhookTarget{URL: srv.URL}, []byte(`{}`))
+
+	q.processDue(srv.Client())
+
+	_, retried, _, depth := q.stats()
+	if retried != 1 {
+		t.Fatalf("expected 1 retried, got %d", retried)
+	}
+	if depth != 1 {
+		t.Fatalf("expected the item to remain queued for retry, depth=%d", depth)
+	}
+	if q.items[0].Attempts != 1 {
+		t.Fatalf("expected Attempts to advance to 1, got %d", q.items[0].Attempts)
+	}
+	if !q.items[0].NextAttempt.After(time.Now()) {
+		t.Fatalf("expected NextAttempt to be pushed into the future by backoff")
+	}
+}
+
+func TestDeliveryQueueDropsExpiredItems(t *testing.T) {
+	q := newDeliveryQueue(10, "", time.Minute)
+	q.enqueue(WebhookTarget{URL: "http://example.invalid"}, []byte(`{}`))
+	// Backdate the item past its TTL without waiting for real time to pass.
+	q.items[0].Enqueued = time.Now().Add(-2 * time.Minute)
+
+	q.processDue(http.DefaultClient)
+
+	_, _, dropped, depth := q.stats()
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped for TTL expiry, got %d", dropped)
+	}
+	if depth != 0 {
+		t.Fatalf("expected the expired item to be removed, depth=%d", depth)
+	}
+}
+
+func TestBackoffWithJitterProgressesAndCaps(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: backoffBase, 2: 2 * backoffBase, 20: backoffCap} {
+		for i := 0; i < 20; i++ {
+			d := backoffWithJitter(attempts)
+			if d < 0 || d > want {
+				t.Fatalf("backoffWithJitter(%d) = %v, want within [0, %v]", attempts, d, want)
+			}
+		}
+	}
+}
+
+func TestDeliveryQueueReplaySpool(t *testing.T) {
+	dir := t.TempDir()
+	spoolPath := filepath.Join(dir, "spool.ndjson")
+
+	q := newDeliveryQueue(10, spoolPath, time.Hour)
+	q.enqueue(WebhookTarget{URL: "http://example.invalid/fresh"}, []byte(`{"a":1}`))
+	q.enqueue(WebhookTarget{URL: "http://example.invalid/expired"}, []byte(`{"b":2}`))
+	// Backdate the second entry so replay drops it as expired, then
+	// persist the backdated state to disk.
+	q.items[1].Enqueued = time.Now().Add(-2 * time.Hour)
+	q.persistLocked()
+
+	replayed := newDeliveryQueue(10, spoolPath, time.Hour)
+	if err := replayed.replaySpool(); err != nil {
+		t.Fatalf("replaySpool: %v", err)
+	}
+
+	_, _, dropped, depth := replayed.stats()
+	if depth != 1 {
+		t.Fatalf("expected 1 surviving item after replay, got %d", depth)
+	}
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped for TTL expiry during replay, got %d", dropped)
+	}
+	if replayed.items[0].Target.URL != "http://example.invalid/fresh" {
+		t.Fatalf("expected the fresh item to survive replay, got %s", replayed.items[0].Target.URL)
+	}
+}